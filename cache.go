@@ -0,0 +1,126 @@
+package treerouter
+
+import (
+	"container/list"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheStats counts the lookups of dynamic routes answered by the route cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// cacheCounters are kept by the routes holder so they add up across tables
+type cacheCounters struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type routeCacheKey struct {
+	method string
+	path   string
+}
+
+// cachedRoute is a resolved dynamic route, its params hold their own copy of the values
+type cachedRoute struct {
+	key      routeCacheKey
+	params   Params
+	handler  http.Handler
+	pattern  string
+	basePath string
+	meta     *RouteMeta
+}
+
+// routeCache keeps the most recently resolved dynamic routes of a table. A table never
+// changes once published, so its cache is dropped along with it when routes change
+type routeCache struct {
+	size     int
+	counters *cacheCounters
+
+	mu      sync.Mutex
+	entries map[routeCacheKey]*list.Element
+	// most recently used first
+	order *list.List
+}
+
+func newRouteCache(size int, counters *cacheCounters) *routeCache {
+	return &routeCache{
+		size:     size,
+		counters: counters,
+		entries:  make(map[routeCacheKey]*list.Element, size),
+		order:    list.New(),
+	}
+}
+
+// get fills v with the route cached for method and path
+func (c *routeCache) get(method, path string, v *routeValue) bool {
+	c.mu.Lock()
+	e, ok := c.entries[routeCacheKey{method: method, path: path}]
+	if !ok {
+		c.mu.Unlock()
+		c.counters.misses.Add(1)
+		return false
+	}
+	c.order.MoveToFront(e)
+	route := e.Value.(*cachedRoute)
+	c.mu.Unlock()
+	c.counters.hits.Add(1)
+
+	v.params = append(v.params[:0], route.params...)
+	v.handler = route.handler
+	v.pattern = route.pattern
+	v.basePath = route.basePath
+	v.meta = route.meta
+	v.tsr = false
+	return true
+}
+
+// add caches the route v resolved for method and path, evicting the least recently used one when full
+func (c *routeCache) add(method, path string, v *routeValue) {
+	route := &cachedRoute{
+		key:      routeCacheKey{method: method, path: path},
+		params:   slices.Clone(v.params),
+		handler:  v.handler,
+		pattern:  v.pattern,
+		basePath: v.basePath,
+		meta:     v.meta,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[route.key]; ok {
+		return
+	}
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedRoute).key)
+	}
+	c.entries[route.key] = c.order.PushFront(route)
+}
+
+// cachedMatch looks up path in the static routes, then in c before walking the tree.
+// Only exact matches of routes with params are cached, static routes have their own index
+func (t *methodTree) cachedMatch(c *routeCache, method, path string, v *routeValue) bool {
+	if c == nil {
+		return t.match(path, v)
+	}
+	if _, ok := t.static[path]; ok {
+		return t.match(path, v)
+	}
+	if c.get(method, path, v) {
+		return true
+	}
+
+	if !t.match(path, v) {
+		return false
+	}
+	if !v.tsr && len(v.params) > 0 {
+		c.add(method, path, v)
+	}
+	return true
+}
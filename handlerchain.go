@@ -2,6 +2,8 @@ package treerouter
 
 import (
 	"net/http"
+	"reflect"
+	"runtime"
 )
 
 type HandlerChain struct {
@@ -38,3 +40,17 @@ func NewChainable(h http.HandlerFunc) chainable {
 		h(hc.writer, hc.request)
 	}
 }
+
+// middlewareNames returns the function names of the middlewares in h, excluding the final handler
+func middlewareNames(h http.Handler) []string {
+	chain, ok := h.(HandlerChain)
+	if !ok || len(chain.Handlers) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(chain.Handlers)-1)
+	for _, c := range chain.Handlers[:len(chain.Handlers)-1] {
+		names = append(names, runtime.FuncForPC(reflect.ValueOf(c).Pointer()).Name())
+	}
+	return names
+}
@@ -0,0 +1,31 @@
+package treerouter
+
+import (
+	"net/http"
+)
+
+// RouteMeta holds descriptive data attached to a route at registration, e.g.
+//
+//	r.GET("/orders/:id", handler, treerouter.RouteMeta{Name: "order", Scopes: []string{"orders:read"}})
+type RouteMeta struct {
+	Name        string
+	Description string
+	// Owner is the team responsible for the route
+	Owner string
+	Tags  []string
+	// Scopes lists the OAuth scopes required to access the route
+	Scopes []string
+	// Values holds any other application defined metadata
+	Values map[string]any
+}
+
+// RouteMetadata returns the metadata of the route that matched r. It returns false
+// if r has not been matched by the router or the route was registered without metadata.
+// The returned RouteMeta is shared by all requests to the route and must not be modified
+func RouteMetadata(r *http.Request) (*RouteMeta, bool) {
+	c, ok := routeContextFrom(r)
+	if !ok || c.meta == nil {
+		return nil, false
+	}
+	return c.meta, true
+}
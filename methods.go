@@ -0,0 +1,542 @@
+package treerouter
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// standard methods in the order they are listed in Allow headers
+var stdMethods = [...]string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// methodIndex returns the index of method in stdMethods or -1 for a non standard method
+func methodIndex(method string) int {
+	switch method {
+	case http.MethodGet:
+		return 0
+	case http.MethodHead:
+		return 1
+	case http.MethodPost:
+		return 2
+	case http.MethodPut:
+		return 3
+	case http.MethodPatch:
+		return 4
+	case http.MethodDelete:
+		return 5
+	case http.MethodConnect:
+		return 6
+	case http.MethodOptions:
+		return 7
+	case http.MethodTrace:
+		return 8
+	}
+	return -1
+}
+
+// maximum number of request paths whose allowed methods are cached
+const maxAllowedCache = 1024
+
+// routes publishes the routing table shared by a router and its groups. Tables are
+// never modified once published: writers copy the parts they change into a draft table
+// swapped in atomically, so requests in flight finish on the table they started with.
+// The draft is published by the next load, so routes registered in a row are copied once
+type routes struct {
+	// serializes writers and publication
+	mu      sync.Mutex
+	current atomic.Pointer[routeTable]
+
+	// changes not published yet, pending is set while there is a draft
+	draft   *routeTable
+	pending atomic.Bool
+
+	// capacity of the route cache of each table, 0 when caching is disabled
+	cacheSize     int
+	cacheCounters cacheCounters
+}
+
+// routeTable holds one tree per method
+type routeTable struct {
+	// trees of the standard methods indexed by methodIndex, nil until a route is added
+	std [len(stdMethods)]*methodTree
+	// trees of non standard methods
+	custom map[string]*methodTree
+
+	// allowed methods per request path, a new table starts with an empty cache
+	allowedMu sync.Mutex
+	allowed   map[string][]string
+
+	// set by Router.Freeze, no route can be added afterwards
+	frozen bool
+
+	// resolved dynamic routes, nil when caching is disabled
+	cache *routeCache
+
+	// redirect policies of groups by base path
+	redirects map[string]*RedirectPolicy
+	// trailing slash modes of groups by base path
+	trailingSlashes map[string]TrailingSlash
+
+	// named routes by name
+	names map[string]namedRoute
+
+	// trees already copied into a draft, nil once the table is published
+	owned map[*methodTree]bool
+}
+
+// namedRoute is the pattern shared by the routes registered with a name, one per method
+type namedRoute struct {
+	pattern string
+	routes  int
+}
+
+type route struct {
+	method string
+	tree   *methodTree
+}
+
+// methodTree is the radix tree of a method along with an index of its static routes
+type methodTree struct {
+	root *node
+	// fully static patterns, and the paths they are a trailing slash redirect for,
+	// looked up before walking the tree
+	static map[string]staticRoute
+}
+
+type staticRoute struct {
+	// endpoint of the static pattern, nil for a trailing slash redirect
+	leaf *node
+	tsr  bool
+}
+
+func newMethodRoot() *routes {
+	m := &routes{}
+	m.current.Store(&routeTable{})
+	return m
+}
+
+// load returns the current routing table, which must not be modified. Pending changes
+// are published first
+func (m *routes) load() *routeTable {
+	if m.pending.Load() {
+		m.publish()
+	}
+	return m.current.Load()
+}
+
+// publish swaps in the draft table
+func (m *routes) publish() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.draft == nil {
+		return
+	}
+	m.draft.owned = nil
+	// the routes of the new table may differ, so it starts with an empty cache
+	if m.cacheSize > 0 {
+		m.draft.cache = newRouteCache(m.cacheSize, &m.cacheCounters)
+	}
+	m.current.Store(m.draft)
+	m.draft = nil
+	m.pending.Store(false)
+}
+
+// update calls fn with the draft table, a copy of the current table made by the first
+// update since the last publication. fn must copy any tree it changes with mutableTree
+// and must not panic once it has changed the draft
+func (m *routes) update(fn func(table *routeTable)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.draft == nil {
+		current := m.current.Load()
+		m.draft = &routeTable{
+			std:             current.std,
+			custom:          maps.Clone(current.custom),
+			frozen:          current.frozen,
+			redirects:       maps.Clone(current.redirects),
+			trailingSlashes: maps.Clone(current.trailingSlashes),
+			names:           maps.Clone(current.names),
+			owned:           make(map[*methodTree]bool),
+		}
+	}
+	fn(m.draft)
+	m.pending.Store(true)
+}
+
+// setCacheSize sets the capacity of the route cache, 0 disables it
+func (m *routes) setCacheSize(size int) {
+	m.update(func(*routeTable) {
+		m.cacheSize = max(size, 0)
+	})
+}
+
+// get returns the tree of method, or nil if no route has been added for it
+func (m *routeTable) get(method string) *methodTree {
+	if i := methodIndex(method); i >= 0 {
+		return m.std[i]
+	}
+	return m.custom[method]
+}
+
+// mutableTree replaces the tree of method in a draft table with a copy that can be
+// modified, creating the tree if needed. A tree is copied once per draft, and only its
+// root node: the nodes below it are copied by the operations changing them
+func (m *routeTable) mutableTree(method string) *methodTree {
+	current := m.get(method)
+	if m.owned[current] {
+		return current
+	}
+
+	t := &methodTree{
+		root:   newNode("/"),
+		static: make(map[string]staticRoute),
+	}
+	if current != nil {
+		t.root = current.root.clone()
+		t.static = maps.Clone(current.static)
+	}
+	m.set(method, t)
+	m.owned[t] = true
+	return t
+}
+
+// set replaces the tree of method in a table being updated, a nil tree removes the method
+func (m *routeTable) set(method string, t *methodTree) {
+	if i := methodIndex(method); i >= 0 {
+		m.std[i] = t
+		return
+	}
+	if t == nil {
+		delete(m.custom, method)
+		return
+	}
+	if m.custom == nil {
+		m.custom = make(map[string]*methodTree)
+	}
+	m.custom[method] = t
+}
+
+// addNode registers path in the tree of method and publishes the new table, with the
+// static route index kept consistent with the tree
+func (m *routes) addNode(method, path string, handlers http.Handler, basePath string, meta *RouteMeta) {
+	m.update(func(table *routeTable) {
+		if table.frozen {
+			panic("cannot add route " + method + " " + path + " to a frozen router")
+		}
+
+		// a malformed pattern panics before the draft is changed
+		checkPattern(path)
+
+		// the same name may only be shared by routes of different methods with the same pattern
+		if meta != nil && meta.Name != "" {
+			if named, ok := table.names[meta.Name]; ok && named.pattern != path {
+				panic("route name " + meta.Name + " is already used by " + named.pattern)
+			}
+		}
+
+		t := table.mutableTree(method)
+		leaf := t.root.addNode(path, handlers)
+		// a route registered again replaces the name of the previous one
+		table.unname(leaf.meta)
+		leaf.pattern = path
+		leaf.basePath = basePath
+		leaf.meta = meta
+		table.name(meta, path)
+
+		if isStatic(path) {
+			t.static[path] = staticRoute{leaf: leaf}
+		}
+		t.indexTrailingSlashes(path)
+	})
+}
+
+// remove unregisters the route of method with pattern and publishes the new table.
+// It reports whether such a route was registered
+func (m *routes) remove(method, pattern string) bool {
+	removed := false
+	m.update(func(table *routeTable) {
+		if table.frozen {
+			panic("cannot remove route " + method + " " + pattern + " from a frozen router")
+		}
+		removed = table.removeRoute(method, pattern)
+	})
+	return removed
+}
+
+// removeAll unregisters every route whose pattern is basePath or below it
+func (m *routes) removeAll(basePath string) {
+	m.update(func(table *routeTable) {
+		if table.frozen {
+			panic("cannot remove routes under " + basePath + " from a frozen router")
+		}
+		for _, methodNode := range table.list() {
+			var patterns []string
+			methodNode.tree.root.walk(func(leaf *node) error {
+				if isUnder(leaf.pattern, basePath) {
+					patterns = append(patterns, leaf.pattern)
+				}
+				return nil
+			})
+			for _, pattern := range patterns {
+				table.removeRoute(methodNode.method, pattern)
+			}
+		}
+	})
+}
+
+// removeRoute removes pattern from the tree of method in a table being updated, dropping
+// the tree once it has no route left
+func (m *routeTable) removeRoute(method, pattern string) bool {
+	if m.get(method) == nil {
+		return false
+	}
+
+	// the root is left as it was when pattern is not found
+	t := m.mutableTree(method)
+	removed := t.root.remove(pattern, pattern)
+	if removed == nil {
+		return false
+	}
+	m.unname(removed.meta)
+	if t.root.isEmpty() {
+		m.set(method, nil)
+		return true
+	}
+	delete(t.static, pattern)
+	t.indexTrailingSlashes(pattern)
+	return true
+}
+
+// indexTrailingSlashes updates the trailing slash redirects of the static patterns after
+// pattern was added or removed. Only the counterparts of its siblings can change: the
+// paths a route matches all start with the static prefix of its pattern, and the nodes
+// split or merged for it only change the redirects of the paths ending where they do
+func (t *methodTree) indexTrailingSlashes(pattern string) {
+	prefix := pattern
+	if i := strings.IndexAny(pattern, ":*"); i >= 0 {
+		prefix = pattern[:i]
+	}
+
+	routeValue := getRouteValue()
+	defer putRouteValue(routeValue)
+
+	// a removed pattern is no longer in the tree
+	t.indexCounterpart(pattern, routeValue)
+	buf := make([]byte, 0, len(prefix)+1)
+	for i := 1; i <= len(prefix); i++ {
+		// looking up a converted key does not allocate, the path is only made for a route
+		buf = append(append(buf[:0], prefix[:i]...), '/')
+		if _, ok := t.static[string(buf)]; ok {
+			t.indexCounterpart(string(buf), routeValue)
+		}
+		if i < len(prefix) && prefix[i] == '/' {
+			t.indexCounterpart(prefix[:i], routeValue)
+		}
+	}
+	if n := t.root.prefixed(prefix); n != nil {
+		n.walk(func(leaf *node) error {
+			if isStatic(leaf.pattern) {
+				t.indexCounterpart(leaf.pattern, routeValue)
+			}
+			return nil
+		})
+	}
+}
+
+// indexCounterpart records whether the path differing from p by a trailing slash is a
+// redirect to the static route of p
+func (t *methodTree) indexCounterpart(p string, routeValue *routeValue) {
+	// a path ending with two slashes is not the counterpart of its counterpart
+	counterpart := toggleTrailingSlash(p)
+	if counterpart == "" || toggleTrailingSlash(counterpart) != p {
+		return
+	}
+	indexed, hasIndex := t.static[counterpart]
+	if hasIndex && !indexed.tsr {
+		// the counterpart has a route of its own
+		return
+	}
+
+	s, ok := t.static[p]
+	if ok && !s.tsr && t.root.match(counterpart, routeValue) && routeValue.tsr {
+		t.static[counterpart] = staticRoute{tsr: true}
+	} else if hasIndex {
+		delete(t.static, counterpart)
+	}
+}
+
+// match looks up path in the static routes before walking the tree
+func (t *methodTree) match(path string, v *routeValue) bool {
+	if s, ok := t.static[path]; ok {
+		v.params = v.params[:0]
+		if s.tsr {
+			v.tsr = true
+			return true
+		}
+		return v.setLeaf(s.leaf, false)
+	}
+	return t.root.match(path, v)
+}
+
+// freeze rejects any further change to the routes
+func (m *routes) freeze() {
+	m.update(func(table *routeTable) {
+		table.frozen = true
+	})
+}
+
+// setRedirectPolicy applies policy to the requests whose path is basePath or below it
+func (m *routes) setRedirectPolicy(basePath string, policy RedirectPolicy) {
+	m.update(func(table *routeTable) {
+		if table.redirects == nil {
+			table.redirects = make(map[string]*RedirectPolicy)
+		}
+		table.redirects[basePath] = &policy
+	})
+}
+
+// redirectPolicy returns the policy of the group with the longest base path containing
+// path, or fallback if no group has its own policy
+func (m *routeTable) redirectPolicy(path string, fallback *RedirectPolicy) *RedirectPolicy {
+	policy, longest := fallback, -1
+	for basePath, p := range m.redirects {
+		if len(basePath) > longest && isUnder(path, basePath) {
+			policy, longest = p, len(basePath)
+		}
+	}
+	return policy
+}
+
+// setTrailingSlash applies mode to the requests whose path is basePath or below it
+func (m *routes) setTrailingSlash(basePath string, mode TrailingSlash) {
+	m.update(func(table *routeTable) {
+		if table.trailingSlashes == nil {
+			table.trailingSlashes = make(map[string]TrailingSlash)
+		}
+		table.trailingSlashes[basePath] = mode
+	})
+}
+
+// trailingSlash returns the mode of the group with the longest base path containing
+// path, or fallback if no group has its own mode
+func (m *routeTable) trailingSlash(path string, fallback TrailingSlash) TrailingSlash {
+	mode, longest := fallback, -1
+	for basePath, groupMode := range m.trailingSlashes {
+		if len(basePath) > longest && isUnder(path, basePath) {
+			mode, longest = groupMode, len(basePath)
+		}
+	}
+	return mode
+}
+
+// isUnder reports whether path is basePath or one of its sub paths
+func isUnder(path, basePath string) bool {
+	if !strings.HasPrefix(path, basePath) {
+		return false
+	}
+	return len(path) == len(basePath) || lastChar(basePath) == '/' || path[len(basePath)] == '/'
+}
+
+// isStatic reports whether pattern has neither params nor a wildcard
+func isStatic(pattern string) bool {
+	return !strings.ContainsAny(pattern, ":*")
+}
+
+// list returns the trees in Allow header order, standard methods first
+func (m *routeTable) list() []route {
+	list := make([]route, 0, len(m.std)+len(m.custom))
+	for i, t := range m.std {
+		if t != nil {
+			list = append(list, route{method: stdMethods[i], tree: t})
+		}
+	}
+
+	custom := make([]string, 0, len(m.custom))
+	for method := range m.custom {
+		custom = append(custom, method)
+	}
+	slices.Sort(custom)
+	for _, method := range custom {
+		list = append(list, route{method: method, tree: m.custom[method]})
+	}
+	return list
+}
+
+// namedPattern returns the pattern of the routes registered with name
+func (m *routeTable) namedPattern(name string) (string, bool) {
+	named, ok := m.names[name]
+	return named.pattern, ok
+}
+
+// name records pattern as the pattern of the route name of meta in a table being updated
+func (m *routeTable) name(meta *RouteMeta, pattern string) {
+	if meta == nil || meta.Name == "" {
+		return
+	}
+	if m.names == nil {
+		m.names = make(map[string]namedRoute)
+	}
+	named := m.names[meta.Name]
+	m.names[meta.Name] = namedRoute{pattern: pattern, routes: named.routes + 1}
+}
+
+// unname forgets a route registered with the name of meta, the name stays in use as long
+// as routes of other methods have it
+func (m *routeTable) unname(meta *RouteMeta) {
+	if meta == nil || meta.Name == "" {
+		return
+	}
+	named, ok := m.names[meta.Name]
+	if !ok {
+		return
+	}
+	if named.routes <= 1 {
+		delete(m.names, meta.Name)
+		return
+	}
+	named.routes--
+	m.names[meta.Name] = named
+}
+
+// allowedMethods returns the methods with a route matching path. Results are cached
+// per path for the lifetime of the table
+func (m *routeTable) allowedMethods(path string) []string {
+	m.allowedMu.Lock()
+	allowed, ok := m.allowed[path]
+	m.allowedMu.Unlock()
+	if ok {
+		return allowed
+	}
+
+	routeValue := getRouteValue()
+	for _, methodNode := range m.list() {
+		if methodNode.tree.match(path, routeValue) {
+			allowed = append(allowed, methodNode.method)
+		}
+	}
+	putRouteValue(routeValue)
+
+	m.allowedMu.Lock()
+	// start over rather than tracking usage, the cache only bounds repeated work
+	if m.allowed == nil || len(m.allowed) >= maxAllowedCache {
+		m.allowed = make(map[string][]string)
+	}
+	m.allowed[path] = allowed
+	m.allowedMu.Unlock()
+	return allowed
+}
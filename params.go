@@ -0,0 +1,234 @@
+package treerouter
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Param is a single route parameter
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params holds the params of a matched route in declaration order, the catch-all
+// value being last with the key "*"
+type Params []Param
+
+// RouteParams is the type of the params returned by ParamsFromRequest
+type RouteParams = Params
+
+// Get returns the value of the first param with the given key, or an empty string
+func (ps Params) Get(key string) string {
+	value, _ := ps.Lookup(key)
+	return value
+}
+
+// Lookup returns the value of the first param with the given key and whether it was found
+func (ps Params) Lookup(key string) (string, bool) {
+	for i := range ps {
+		if ps[i].Key == key {
+			return ps[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// ParamsFromRequest returns the params of the route that matched r. Ranging over the
+// returned params visits them in declaration order. It returns false if r has not
+// been matched by the router
+func ParamsFromRequest(r *http.Request) (RouteParams, bool) {
+	c, ok := routeContextFrom(r)
+	if !ok {
+		return nil, false
+	}
+	return c.params, true
+}
+
+// ErrParamNotFound is returned by the typed param accessors when the route has no such param
+var ErrParamNotFound = errors.New("treerouter: param not found")
+
+// ParamError records a param value that could not be parsed
+type ParamError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	return "treerouter: param " + strconv.Quote(e.Key) + " with value " + strconv.Quote(e.Value) + ": " + e.Err.Error()
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// paramValue returns the value of param key, or an error if it is not set
+func paramValue(r *http.Request, key string) (string, error) {
+	c, ok := routeContextFrom(r)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrParamNotFound, key)
+	}
+	value, ok := c.params.Lookup(key)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrParamNotFound, key)
+	}
+	return value, nil
+}
+
+// ParamInt returns the value of param key parsed as a base 10 int
+func ParamInt(r *http.Request, key string) (int, error) {
+	value, err := paramValue(r, key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &ParamError{Key: key, Value: value, Err: err}
+	}
+	return i, nil
+}
+
+// ParamUUID returns the value of param key parsed as a UUID in its canonical
+// xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form
+func ParamUUID(r *http.Request, key string) ([16]byte, error) {
+	var uuid [16]byte
+	value, err := paramValue(r, key)
+	if err != nil {
+		return uuid, err
+	}
+
+	invalid := &ParamError{Key: key, Value: value, Err: errors.New("invalid UUID format")}
+	if len(value) != 36 || value[8] != '-' || value[13] != '-' || value[18] != '-' || value[23] != '-' {
+		return uuid, invalid
+	}
+	hexValue := value[:8] + value[9:13] + value[14:18] + value[19:23] + value[24:]
+	if _, err := hex.Decode(uuid[:], []byte(hexValue)); err != nil {
+		return [16]byte{}, invalid
+	}
+	return uuid, nil
+}
+
+// ParamTime returns the value of param key parsed with time.Parse using layout
+func ParamTime(r *http.Request, key, layout string) (time.Time, error) {
+	value, err := paramValue(r, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, &ParamError{Key: key, Value: value, Err: err}
+	}
+	return t, nil
+}
+
+// initial capacity of pooled params, enough for most routes without growing
+const defaultParamsCap = 8
+
+var routeValuePool = sync.Pool{
+	New: func() any {
+		return &routeValue{params: make(Params, 0, defaultParamsCap)}
+	},
+}
+
+func getRouteValue() *routeValue {
+	return routeValuePool.Get().(*routeValue)
+}
+
+// putRouteValue returns v to the pool, clearing references so they can be collected
+func putRouteValue(v *routeValue) {
+	clear(v.params)
+	*v = routeValue{params: v.params[:0]}
+	routeValuePool.Put(v)
+}
+
+// unescapeParams decodes the param values of a route matched on an escaped path
+func (v *routeValue) unescapeParams() {
+	for i := range v.params {
+		if value, err := url.PathUnescape(v.params[i].Value); err == nil {
+			v.params[i].Value = value
+		}
+	}
+}
+
+// number of params stored in the request context itself, routes with more params
+// have them copied to their own slice
+const inlineParams = 4
+
+// routeContext is the request context of a matched handler. It is allocated for each
+// request and never changed, so handlers may keep it, or pass it to goroutines, after
+// they return while the route value used for matching goes back to the pool
+type routeContext struct {
+	context.Context
+	params   Params
+	pattern  string
+	basePath string
+	meta     *RouteMeta
+
+	inline [inlineParams]Param
+	// the handler chain run for the request, allocated along with the context
+	chain HandlerChain
+}
+
+// Value makes the matched route available to handlers through the request context
+func (c *routeContext) Value(key any) any {
+	if key == routeKey {
+		return c
+	}
+	return c.Context.Value(key)
+}
+
+// newRouteContext returns the context of r holding a copy of the route matched in v.
+// The parent context is kept so deadlines, cancellation and values set by outer handlers
+// still apply
+func newRouteContext(r *http.Request, v *routeValue) *routeContext {
+	c := &routeContext{
+		Context:  r.Context(),
+		pattern:  v.pattern,
+		basePath: v.basePath,
+		meta:     v.meta,
+	}
+	if len(v.params) > 0 {
+		if len(v.params) <= inlineParams {
+			c.params = c.inline[:len(v.params):len(v.params)]
+			copy(c.params, v.params)
+		} else {
+			c.params = slices.Clone(v.params)
+		}
+	}
+	return c
+}
+
+// serveMatch serves r with the handler of the route matched in v. Handler chains run
+// on the copy kept in the route context, so a middleware can keep running the chain
+// after it returns, e.g. from a goroutine
+func serveMatch(w http.ResponseWriter, r *http.Request, v *routeValue) {
+	c := newRouteContext(r, v)
+	r = r.WithContext(c)
+
+	chain, ok := v.handler.(HandlerChain)
+	if !ok {
+		v.handler.ServeHTTP(w, r)
+		return
+	}
+	c.chain = chain
+	c.chain.writer = w
+	c.chain.request = r
+	c.chain.Next()
+}
+
+// routeContextFrom returns the route stored in the context of r
+func routeContextFrom(r *http.Request) (*routeContext, bool) {
+	c, ok := r.Context().Value(routeKey).(*routeContext)
+	return c, ok
+}
+
+var _ context.Context = (*routeContext)(nil)
@@ -0,0 +1,253 @@
+package treerouter
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RedirectPolicy controls the redirects issued by the router to fix request paths
+type RedirectPolicy struct {
+	// Disabled turns the redirects off, requests that would be redirected to another route
+	// get a 404 instead while requests with a path that is not clean are served as they are
+	Disabled bool
+	// Temporary redirects with 302 for GET requests and 307 for other methods rather than
+	// 301 and 308, so clients do not cache the redirects
+	Temporary bool
+	// TrailingSlash, FixedPath and CleanPath set the status code of each kind of redirect,
+	// the code chosen by Temporary is used when they are zero
+	TrailingSlash int
+	FixedPath     int
+	CleanPath     int
+
+	// ServeInPlace serves the route a request would be redirected to directly, as if the
+	// request had been made to the fixed path, rather than redirecting the client. Requests
+	// matched once RemoveExtraSlash has cleaned their path are rewritten the same way
+	ServeInPlace bool
+	// CanonicalHeader tells clients the fixed path of the requests served in place
+	CanonicalHeader CanonicalHeader
+}
+
+// CanonicalHeader is a header giving the canonical path of a request served in place
+type CanonicalHeader int
+
+const (
+	// NoCanonicalHeader leaves the response headers untouched
+	NoCanonicalHeader CanonicalHeader = iota
+	// ContentLocationHeader sets Content-Location to the fixed path
+	ContentLocationHeader
+	// LinkHeader adds a Link header to the fixed path with rel=canonical
+	LinkHeader
+)
+
+type redirectReason int
+
+const (
+	trailingSlashRedirect redirectReason = iota
+	fixedPathRedirect
+	cleanPathRedirect
+)
+
+// code returns the status code redirecting r for reason
+func (p *RedirectPolicy) code(r *http.Request, reason redirectReason) int {
+	switch {
+	case reason == trailingSlashRedirect && p.TrailingSlash != 0:
+		return p.TrailingSlash
+	case reason == fixedPathRedirect && p.FixedPath != 0:
+		return p.FixedPath
+	case reason == cleanPathRedirect && p.CleanPath != 0:
+		return p.CleanPath
+	}
+
+	// only GET requests may be redirected with a code allowing clients to change the method
+	if p.Temporary {
+		if r.Method == http.MethodGet {
+			return http.StatusFound
+		}
+		return http.StatusTemporaryRedirect
+	}
+	if r.Method == http.MethodGet {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+// toggleTrailingSlash adds the trailing slash of p or removes it, an empty p is kept empty
+func toggleTrailingSlash(p string) string {
+	if p == "" {
+		return ""
+	}
+	if lastChar(p) == '/' {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+// fixRawPath applies to the escaped rawPath the byte changes turning its decoded form
+// path into fixed, keeping the escapes of unchanged bytes. It returns an empty string,
+// which lets the url be escaped from scratch, when the changes cannot be mapped
+func fixRawPath(rawPath, path, fixed string) string {
+	if rawPath == "" || len(path) != len(fixed) {
+		return ""
+	}
+
+	var b strings.Builder
+	b.Grow(len(rawPath))
+	for i, j := 0, 0; i < len(rawPath); j++ {
+		if j == len(path) {
+			return ""
+		}
+		n := 1
+		if rawPath[i] == '%' {
+			n = 3
+		}
+		if i+n > len(rawPath) {
+			return ""
+		}
+		if path[j] == fixed[j] {
+			b.WriteString(rawPath[i : i+n])
+		} else {
+			b.WriteString(url.PathEscape(fixed[j : j+1]))
+		}
+		i += n
+	}
+	return b.String()
+}
+
+// serveRoute serves the route matching path exactly and reports whether there is one.
+// The request is rewritten to target, setting the canonical header of policy, unless
+// policy is nil, in which case it is served as it is
+func serveRoute(w http.ResponseWriter, r *http.Request, route *methodTree, path string, escaped bool, policy *RedirectPolicy, target, rawTarget string) bool {
+	routeValue := getRouteValue()
+	defer putRouteValue(routeValue)
+
+	if !route.match(path, routeValue) || routeValue.tsr {
+		return false
+	}
+	if escaped {
+		routeValue.unescapeParams()
+	}
+	if policy != nil {
+		r = rewritePath(w, r, policy, target, rawTarget)
+	}
+	serveMatch(w, r, routeValue)
+	return true
+}
+
+// rewritePath returns a copy of r for path, setting the canonical header of policy
+func rewritePath(w http.ResponseWriter, r *http.Request, policy *RedirectPolicy, path, rawPath string) *http.Request {
+	u := *r.URL
+	u.Path, u.RawPath = decodedPath(path, rawPath), rawPath
+
+	switch policy.CanonicalHeader {
+	case ContentLocationHeader:
+		w.Header().Set("Content-Location", u.EscapedPath())
+	case LinkHeader:
+		w.Header().Add("Link", "<"+u.EscapedPath()+">; rel=\"canonical\"")
+	}
+
+	rewritten := new(http.Request)
+	*rewritten = *r
+	rewritten.URL = &u
+	return rewritten
+}
+
+// redirect redirects to path, keeping the query of the request. rawPath is the escaped
+// form of path when it differs from the default escaping. The prefix forwarded by a
+// trusted proxy is prepended to the path
+func (router *Router) redirect(w http.ResponseWriter, r *http.Request, code int, path, rawPath string) {
+	if prefix := router.forwardedPrefix(r); prefix != "" {
+		path = prefix + path
+		if rawPath != "" {
+			rawPath = prefix + rawPath
+		}
+	}
+
+	// the location is always an absolute path on the same host, browsers take a path
+	// starting with // or /\ as a url of another host
+	path = localPath(path)
+	if rawPath != "" {
+		rawPath = localPath(rawPath)
+	}
+
+	target := url.URL{
+		Path:     decodedPath(path, rawPath),
+		RawPath:  rawPath,
+		RawQuery: r.URL.RawQuery,
+		Fragment: r.URL.Fragment,
+	}
+	http.Redirect(w, r, target.String(), code)
+}
+
+// decodedPath returns the path escaped by rawPath when it is set, which is the case for
+// paths matched with their encoded slashes, otherwise path
+func decodedPath(path, rawPath string) string {
+	if rawPath == "" {
+		return path
+	}
+	if decoded, err := url.PathUnescape(rawPath); err == nil {
+		return decoded
+	}
+	return path
+}
+
+// localPath returns p starting with a single slash
+func localPath(p string) string {
+	return "/" + strings.TrimLeft(p, "/\\")
+}
+
+// forwardedPrefix returns the X-Forwarded-Prefix header of r without its trailing slash,
+// or an empty string if r does not come from a trusted proxy or the prefix is not a
+// clean absolute path
+func (router *Router) forwardedPrefix(r *http.Request) string {
+	prefix := r.Header.Get("X-Forwarded-Prefix")
+	if prefix == "" || !router.trustsProxy(r.RemoteAddr) {
+		return ""
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || prefix[0] != '/' || path.Clean(prefix) != prefix {
+		return ""
+	}
+	for i := range len(prefix) {
+		if !isPathChar(prefix[i]) {
+			return ""
+		}
+	}
+	return prefix
+}
+
+// trustsProxy reports whether remoteAddr, with or without a port, is in TrustedProxies
+func (router *Router) trustsProxy(remoteAddr string) bool {
+	if len(router.TrustedProxies) == 0 {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(remoteAddr)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(remoteAddr)
+		if err != nil {
+			return false
+		}
+		addr = addrPort.Addr()
+	}
+
+	addr = addr.Unmap()
+	for _, proxy := range router.TrustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPathChar reports whether c may appear unescaped in a url path, see RFC 3986
+func isPathChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~!$&'()*+,;=:@/", c) >= 0
+}
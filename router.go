@@ -2,16 +2,44 @@ package treerouter
 
 import (
 	"net/http"
-	"path"
+	"net/netip"
+	"slices"
 	"strings"
 )
 
 type Router struct {
 	*RouteGroup
-	RedirectTrailingSlash  bool
-	RedirectFixedPath      bool
-	RemoveExtraSlash       bool
+	RedirectTrailingSlash bool
+	RedirectFixedPath     bool
+	// RemoveExtraSlash matches requests whose path is not clean, e.g. /a//b/../c, on the
+	// cleaned path. Its trailing slash is kept, unless only the path without it has a
+	// route and no trailing slash mode is set
+	RemoveExtraSlash bool
+	// RedirectCleanPath redirects requests whose path is not clean to the cleaned path
+	// rather than serving them under the original path as RemoveExtraSlash does. A trailing
+	// slash or fixed path redirect of the cleaned path is done with the same redirect
+	RedirectCleanPath bool
+	// CaseInsensitive serves the route matching the path ignoring case when no route
+	// matches it exactly, without redirecting. Params keep the casing of the request.
+	// Clean path and trailing slash redirects go to the path with the casing of the route
+	CaseInsensitive bool
+	// UseRawPath matches requests whose escaped path has encoded slashes on the escaped
+	// path, so /files/a%2Fb matches /files/:name with the param a/b. Other escapes are
+	// decoded before matching, and param values are unescaped one by one
+	UseRawPath bool
+	// TrailingSlash sets how requests differing from a route by a trailing slash are
+	// handled, groups can override it with RouteGroup.SetTrailingSlash
+	TrailingSlash          TrailingSlash
 	HandleMethodNotAllowed bool
+	// HandleOPTIONS answers OPTIONS requests without a registered OPTIONS route
+	// with the methods allowed for the path
+	HandleOPTIONS bool
+	// TrustedProxies lists the networks of the proxies allowed to set X-Forwarded-Prefix,
+	// which is then prepended to the path of redirects. The header is ignored by default
+	TrustedProxies []netip.Prefix
+	// RedirectPolicy sets the status codes of the redirects enabled above,
+	// groups can override it with RouteGroup.SetRedirectPolicy
+	RedirectPolicy RedirectPolicy
 }
 
 func New() *Router {
@@ -25,6 +53,7 @@ func New() *Router {
 		RedirectFixedPath:      false,
 		RemoveExtraSlash:       false,
 		HandleMethodNotAllowed: false,
+		HandleOPTIONS:          false,
 	}
 }
 
@@ -32,57 +61,248 @@ func (router *Router) NewGroup(path string) *RouteGroup {
 	return router.Bind(path)
 }
 
+// Freeze rejects any further change to the routes, adding or removing a route on a
+// frozen router panics. It guarantees the routes served stay the ones registered at startup
+func (router *Router) Freeze() {
+	router.routes.freeze()
+}
+
+// CacheRoutes keeps up to size resolved dynamic routes, with their param values, keyed by
+// method and path so repeated requests skip walking the tree. The cache is emptied whenever
+// routes change. A size of 0 disables the cache, which is the default
+func (router *Router) CacheRoutes(size int) {
+	router.routes.setCacheSize(size)
+}
+
+// CacheStats returns the number of dynamic route lookups answered by the cache and
+// the number that had to walk the tree since the router was created
+func (router *Router) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:   router.routes.cacheCounters.hits.Load(),
+		Misses: router.routes.cacheCounters.misses.Load(),
+	}
+}
+
+// Remove unregisters the route of method with pattern, e.g. /users/:id, and reports
+// whether it was registered. Requests already being served finish with the removed route
+func (router *Router) Remove(method, pattern string) bool {
+	return router.routes.remove(method, pattern)
+}
+
+// WalkFunc is the function called by Walk for every registered route. middlewares
+// holds the names of the middlewares that run before the route handler
+type WalkFunc func(method, pattern string, handler http.Handler, middlewares []string) error
+
+// Walk calls fn for every registered route, method by method. Walking stops at the
+// first error returned by fn, which is then returned by Walk
+func (router *Router) Walk(fn WalkFunc) error {
+	for _, methodNode := range router.routes.load().list() {
+		err := methodNode.tree.root.walk(func(leaf *node) error {
+			return fn(methodNode.method, leaf.pattern, leaf.handler, middlewareNames(leaf.handler))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	rPath := r.URL.Path
+	rPath, rawPath := r.URL.Path, r.URL.RawPath
 
-	if router.RemoveExtraSlash {
-		rPath = path.Clean(rPath)
+	// encoded slashes are kept in the matched path so they do not end a segment
+	escaped := router.UseRawPath && hasEncodedSlash(rawPath)
+	if escaped {
+		rPath = unescapeExceptSlash(rawPath)
 	}
 
-	route := router.routes.get(r.Method)
+	requested := rPath
+	if router.RedirectCleanPath || router.RemoveExtraSlash {
+		rPath = cleanPath(rPath)
+	}
+	cleaned := rPath != requested
+	if escaped {
+		rawPath = escapePath(rPath)
+	} else if cleaned {
+		rawPath = ""
+	}
+
+	// the table is loaded once so the whole request sees the same routes
+	table := router.routes.load()
+	route := table.get(r.Method)
 	if route != nil {
-		if routeValue := route.match(rPath); routeValue != nil {
-			// if there is no trailing slash mismatch it means an exact match has been found
-			if !routeValue.tsr {
-				r = addParams(r, routeValue.params)
-				routeValue.handler.ServeHTTP(w, r)
+		if router.servePath(w, r, table, route, rPath, rawPath, escaped, cleaned) {
+			return
+		}
+
+		// RemoveExtraSlash drops the trailing slash as well when no trailing slash mode
+		// could handle it, the way path.Clean does
+		if router.RemoveExtraSlash && rPath != "/" && lastChar(rPath) == '/' &&
+			router.trailingSlashMode(table, rPath) == TrailingSlashUnset {
+			stripped, rawStripped := toggleTrailingSlash(rPath), toggleTrailingSlash(rawPath)
+			if router.servePath(w, r, table, route, stripped, rawStripped, escaped, true) {
 				return
 			}
-			if router.RedirectTrailingSlash {
-				redirectTrailingSlash(w, r)
-				return
+		}
+
+		// the request is handled as if its path had the casing of the route, keeping its
+		// trailing slash, so that it is cleaned and its trailing slash fixed the same way
+		if router.CaseInsensitive {
+			if fixed, ok := route.root.findCaseInsensitivePath(rPath, true); ok {
+				if (lastChar(fixed) == '/') != (lastChar(rPath) == '/') {
+					fixed = toggleTrailingSlash(fixed)
+				}
+				rawFixed := fixRawPath(rawPath, rPath, fixed)
+				if escaped {
+					rawFixed = escapePath(fixed)
+				}
+				if fixed != rPath && router.servePath(w, r, table, route, fixed, rawFixed, escaped, cleaned) {
+					return
+				}
 			}
 		}
 
-		// if a route is not found, try finding case insensitive matches
+		// if a route is not found, try finding case insensitive matches. The redirect policy
+		// and trailing slash mode are the ones of the path redirected to
 		if router.RedirectFixedPath {
-			if path, ok := route.findCaseInsensitivePath(rPath, router.RedirectFixedPath); ok {
-				r.URL.Path = path
-				redirectRoute(w, r)
-				return
+			if fixed, mode, ok := router.fixedPath(table, route, rPath); ok {
+				if policy := table.redirectPolicy(fixed, &router.RedirectPolicy); !policy.Disabled {
+					rawFixed := fixRawPath(rawPath, rPath, fixed)
+					if escaped {
+						rawFixed = escapePath(fixed)
+					}
+					// the fixed path is redirected to in its canonical form, which the
+					// route of the fixed path serves as well
+					target, rawTarget := fixed, rawFixed
+					if !mode.canonical(fixed) && route.matches(toggleTrailingSlash(fixed)) {
+						target, rawTarget = toggleTrailingSlash(fixed), toggleTrailingSlash(rawFixed)
+					}
+					if !policy.ServeInPlace {
+						router.redirect(w, r, policy.code(r, fixedPathRedirect), target, rawTarget)
+						return
+					}
+					if serveRoute(w, r, route, fixed, escaped, policy, target, rawTarget) {
+						return
+					}
+				}
 			}
 		}
 	}
 
+	if router.HandleOPTIONS && r.Method == http.MethodOptions {
+		if allowed := table.allowedMethods(rPath); len(allowed) > 0 {
+			if !slices.Contains(allowed, http.MethodOptions) {
+				allowed = append(allowed[:len(allowed):len(allowed)], http.MethodOptions)
+			}
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
 	if router.HandleMethodNotAllowed {
-		router.methodNotAllowedHandler().ServeHTTP(w, r)
+		methodNotAllowedHandler(table, rPath).ServeHTTP(w, r)
 		return
 	}
 
 	http.NotFoundHandler().ServeHTTP(w, r)
 }
 
-func (router *Router) methodNotAllowedHandler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		allowedMethods := make([]string, 0, len(router.routes))
-		p := r.URL.Path
-		for _, methodNode := range router.routes {
-			if methodNode.method == r.Method {
-				continue
+// servePath serves the request with the route matching p, the request path once cleaned,
+// or with the route of the path differing from p by a trailing slash as the trailing slash
+// mode of p allows. The request is redirected or rewritten in place to the path of the route
+// when its trailing slash is fixed, and to p when it was cleaned. It reports whether the
+// request was served
+func (router *Router) servePath(w http.ResponseWriter, r *http.Request, table *routeTable, route *methodTree, p, rawP string, escaped, cleaned bool) bool {
+	mode := router.trailingSlashMode(table, p)
+	routeValue := getRouteValue()
+	if !route.cachedMatch(table.cache, r.Method, p, routeValue) {
+		putRouteValue(routeValue)
+		return false
+	}
+
+	tsr := routeValue.tsr
+	target, rawTarget, reason := p, rawP, cleanPathRedirect
+	switch {
+	// the route of the other path serves the request as it is, unless its path is not clean
+	case tsr && (mode == TrailingSlashLenient || mode.canonicalizes() && mode.canonical(p)):
+	case tsr && (mode == TrailingSlashRedirect || mode.canonicalizes()),
+		// the path of an exact match is fixed when the other path is canonical and matches too
+		!tsr && !mode.canonical(p) && route.matches(toggleTrailingSlash(p)):
+		target, rawTarget, reason = toggleTrailingSlash(p), toggleTrailingSlash(rawP), trailingSlashRedirect
+	case tsr:
+		putRouteValue(routeValue)
+		return false
+	}
+
+	// the route of the other path must match it exactly to serve the request
+	if tsr {
+		putRouteValue(routeValue)
+		routeValue = getRouteValue()
+		if !route.match(toggleTrailingSlash(p), routeValue) || routeValue.tsr {
+			putRouteValue(routeValue)
+			return false
+		}
+	}
+	defer putRouteValue(routeValue)
+	if escaped {
+		routeValue.unescapeParams()
+	}
+
+	if cleaned || reason == trailingSlashRedirect {
+		policy := table.redirectPolicy(target, &router.RedirectPolicy)
+		switch {
+		case policy.ServeInPlace:
+			r = rewritePath(w, r, policy, target, rawTarget)
+		case policy.Disabled:
+			// a request is only served under another path by a trailing slash redirect
+			if tsr && reason == trailingSlashRedirect {
+				return false
 			}
+		case reason == trailingSlashRedirect || router.RedirectCleanPath:
+			router.redirect(w, r, policy.code(r, reason), target, rawTarget)
+			return true
+		}
+	}
+	serveMatch(w, r, routeValue)
+	return true
+}
 
-			if result := methodNode.node.match(p); result != nil {
-				allowedMethods = append(allowedMethods, methodNode.method)
+// trailingSlashMode returns the trailing slash mode of the requests for path
+func (router *Router) trailingSlashMode(table *routeTable, path string) TrailingSlash {
+	mode := table.trailingSlash(path, router.TrailingSlash)
+	if mode == TrailingSlashUnset && router.RedirectTrailingSlash {
+		mode = TrailingSlashRedirect
+	}
+	return mode
+}
+
+// fixedPath finds the route path matching p ignoring case, along with its trailing slash
+// mode. A path differing from p by a trailing slash is only found if that mode allows it
+func (router *Router) fixedPath(table *routeTable, route *methodTree, p string) (string, TrailingSlash, bool) {
+	fixed, ok := route.root.findCaseInsensitivePath(p, true)
+	if !ok {
+		return "", TrailingSlashUnset, false
+	}
+	mode := router.trailingSlashMode(table, fixed)
+	if mode == TrailingSlashStrict && (lastChar(fixed) == '/') != (lastChar(p) == '/') {
+		if fixed, ok = route.root.findCaseInsensitivePath(p, false); !ok {
+			return "", TrailingSlashUnset, false
+		}
+		mode = router.trailingSlashMode(table, fixed)
+	}
+	return fixed, mode, true
+}
+
+// methodNotAllowedHandler answers with the methods allowed for path, the path of the
+// request as it is matched
+func methodNotAllowedHandler(table *routeTable, path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the cached methods are shared, so the request method is filtered into a new slice
+		allowedMethods := make([]string, 0, len(stdMethods))
+		for _, method := range table.allowedMethods(path) {
+			if method != r.Method {
+				allowedMethods = append(allowedMethods, method)
 			}
 		}
 
@@ -94,31 +314,3 @@ func (router *Router) methodNotAllowedHandler() http.Handler {
 		}
 	})
 }
-
-func redirectTrailingSlash(w http.ResponseWriter, r *http.Request) {
-	rPath := r.URL.Path
-	pLength := len(rPath)
-
-	if rPath[pLength-1] == '/' {
-		rPath = rPath[:pLength-1]
-	} else {
-		rPath += "/"
-	}
-
-	// path.Clean returns "." when arg is empty string
-	if prefix := path.Clean(r.Header.Get("X-Forwarded-Prefix")); prefix != "." {
-		rPath = prefix + "/" + rPath
-	}
-
-	r.URL.Path = rPath
-	redirectRoute(w, r)
-}
-
-func redirectRoute(w http.ResponseWriter, r *http.Request) {
-	// set 301 status for Get requests, 308 for non-Get requests
-	code := http.StatusMovedPermanently
-	if r.Method != http.MethodGet {
-		code = http.StatusPermanentRedirect
-	}
-	http.Redirect(w, r, r.URL.Path, code)
-}
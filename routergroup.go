@@ -7,10 +7,10 @@ import (
 type RouteGroup struct {
 	BasePath    string
 	Middlewares []chainable
-	routes      routes
+	routes      *routes
 }
 
-func NewGroup(basePath string, methods routes, middlewares ...chainable) *RouteGroup {
+func NewGroup(basePath string, methods *routes, middlewares ...chainable) *RouteGroup {
 	return &RouteGroup{
 		BasePath:    basePath,
 		Middlewares: middlewares,
@@ -29,50 +29,78 @@ func (group *RouteGroup) Bind(path string) *RouteGroup {
 	return newGroup
 }
 
+// SetRedirectPolicy replaces the redirect policy of the router for the requests whose path
+// is the group path or below it, e.g. to disable redirects for an API group
+func (group *RouteGroup) SetRedirectPolicy(policy RedirectPolicy) {
+	group.routes.setRedirectPolicy(group.BasePath, policy)
+}
+
+// SetTrailingSlash replaces the trailing slash mode of the router for the requests whose
+// path is the group path or below it
+func (group *RouteGroup) SetTrailingSlash(mode TrailingSlash) {
+	group.routes.setTrailingSlash(group.BasePath, mode)
+}
+
+// RemoveAll unregisters the routes of every method whose pattern is the group path or below it
+func (group *RouteGroup) RemoveAll() {
+	group.routes.removeAll(group.BasePath)
+}
+
 func (group *RouteGroup) Use(middlewares ...chainable) {
 	group.Middlewares = append(group.Middlewares, middlewares...)
 }
 
-// GET is a helper function for creating Get route in treerouter
-func (group *RouteGroup) GET(path string, handler http.HandlerFunc) *RouteGroup {
-	combinedPath := group.addRoute(path, http.MethodGet, handler)
+// Handle registers handler for requests with the given method, which may be any
+// standard or custom http method, and returns a group for the route path
+func (group *RouteGroup) Handle(method, path string, handler http.HandlerFunc, meta ...RouteMeta) *RouteGroup {
+	combinedPath := group.addRoute(path, method, handler, meta)
 	return NewGroup(combinedPath, group.routes)
 }
 
+// GET is a helper function for creating Get route in treerouter
+func (group *RouteGroup) GET(path string, handler http.HandlerFunc, meta ...RouteMeta) *RouteGroup {
+	return group.Handle(http.MethodGet, path, handler, meta...)
+}
+
 // POST is a helper function for creating Post route in treerouter
-func (group *RouteGroup) POST(path string, handler http.HandlerFunc) *RouteGroup {
-	combinedPath := group.addRoute(path, http.MethodPost, handler)
-	return NewGroup(combinedPath, group.routes)
+func (group *RouteGroup) POST(path string, handler http.HandlerFunc, meta ...RouteMeta) *RouteGroup {
+	return group.Handle(http.MethodPost, path, handler, meta...)
 }
 
 // PUT is a helper function for creating Put route in treerouter
-func (group *RouteGroup) PUT(path string, handler http.HandlerFunc) *RouteGroup {
-	combinedPath := group.addRoute(path, http.MethodPut, handler)
-	return NewGroup(combinedPath, group.routes)
+func (group *RouteGroup) PUT(path string, handler http.HandlerFunc, meta ...RouteMeta) *RouteGroup {
+	return group.Handle(http.MethodPut, path, handler, meta...)
 }
 
 // PATCH is a helper function for creating Patch route in treerouter
-func (group *RouteGroup) PATCH(path string, handler http.HandlerFunc) *RouteGroup {
-	combinedPath := group.addRoute(path, http.MethodPatch, handler)
-	return NewGroup(combinedPath, group.routes)
+func (group *RouteGroup) PATCH(path string, handler http.HandlerFunc, meta ...RouteMeta) *RouteGroup {
+	return group.Handle(http.MethodPatch, path, handler, meta...)
 }
 
 // DELETE is a helper function for creating Delete route in treerouter
-func (group *RouteGroup) DELETE(path string, handler http.HandlerFunc) *RouteGroup {
-	combinedPath := group.addRoute(path, http.MethodDelete, handler)
-	return NewGroup(combinedPath, group.routes)
+func (group *RouteGroup) DELETE(path string, handler http.HandlerFunc, meta ...RouteMeta) *RouteGroup {
+	return group.Handle(http.MethodDelete, path, handler, meta...)
 }
 
-// addRoute appends route handler to the middlewares and forms a HandlerChain
-func (group *RouteGroup) addRoute(relativePath, method string, handler http.HandlerFunc) string {
+// addRoute appends route handler to the middlewares and forms a HandlerChain.
+// At most one RouteMeta can be attached to a route
+func (group *RouteGroup) addRoute(relativePath, method string, handler http.HandlerFunc, meta []RouteMeta) string {
+	if len(meta) > 1 {
+		panic("at most one RouteMeta can be attached to a route")
+	}
 	combinedPath := joinPaths(group.BasePath, relativePath)
-	handlers := append(group.Middlewares, NewChainable(handler))
-	hChain := NewHandlerChain(handlers...)
 
-	methodRoot := group.routes.get(method)
-	if methodRoot != nil {
-		methodRoot.addNode(combinedPath, hChain)
+	// the chain gets its own slice, appending to the middlewares could share their
+	// spare capacity with the chains of other routes and later Use calls
+	handlers := make([]chainable, 0, len(group.Middlewares)+1)
+	handlers = append(handlers, group.Middlewares...)
+	hChain := NewHandlerChain(append(handlers, NewChainable(handler))...)
+
+	var routeMeta *RouteMeta
+	if len(meta) == 1 {
+		routeMeta = &meta[0]
 	}
+	group.routes.addNode(method, combinedPath, hChain, group.BasePath, routeMeta)
 
 	return combinedPath
 }
@@ -0,0 +1,127 @@
+package treerouter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	expiresQueryKey   = "expires"
+	signatureQueryKey = "signature"
+)
+
+// timeNow is replaced in tests
+var timeNow = time.Now
+
+// SignOptions sets what a signed url is valid for besides its route
+type SignOptions struct {
+	// Method is the only method the url can be used with, GET if empty
+	Method string
+	// Query is signed along with the path and added to the url
+	Query url.Values
+}
+
+// SignedURL builds the path of the named route like URL and appends an expiry time and
+// an HMAC-SHA256 signature computed with key. The signature covers the method, the route
+// name, the path and the whole query, so a request adding params to the url is rejected.
+// Signed URLs are verified by the VerifySignedURL middleware. At most one SignOptions
+// can be given
+func (router *Router) SignedURL(name string, params []string, expiry time.Duration, key []byte, options ...SignOptions) (string, error) {
+	if len(options) > 1 {
+		panic("at most one SignOptions can be given for a signed url")
+	}
+	if expiry <= 0 {
+		return "", errors.New("treerouter: signed url expiry must be positive")
+	}
+	if len(key) == 0 {
+		return "", errors.New("treerouter: signed url key cannot be empty")
+	}
+
+	method := http.MethodGet
+	query := url.Values{}
+	if len(options) == 1 {
+		if options[0].Method != "" {
+			method = options[0].Method
+		}
+		for k, values := range options[0].Query {
+			if k == expiresQueryKey || k == signatureQueryKey {
+				return "", errors.New("treerouter: signed url query cannot set " + k)
+			}
+			query[k] = append([]string(nil), values...)
+		}
+	}
+
+	p, err := router.URL(name, params...)
+	if err != nil {
+		return "", err
+	}
+	// the signature covers the decoded path which is what the router matches against
+	decoded, err := url.PathUnescape(p)
+	if err != nil {
+		return "", err
+	}
+
+	query.Set(expiresQueryKey, strconv.FormatInt(timeNow().Add(expiry).Unix(), 10))
+	signature := sign(key, method, name, decoded, query.Encode())
+	query.Set(signatureQueryKey, signature)
+	return p + "?" + query.Encode(), nil
+}
+
+// VerifySignedURL returns a middleware that only lets requests through if they carry a
+// valid, unexpired signature created by SignedURL for the matched route and the method
+// of the request, with no param added to the signed query. It responds with 403 otherwise
+func VerifySignedURL(key []byte) chainable {
+	return func(hc *HandlerChain) {
+		meta, ok := RouteMetadata(hc.request)
+		if !ok || meta.Name == "" {
+			http.Error(hc.writer, "403 route does not accept signed urls", http.StatusForbidden)
+			return
+		}
+
+		// the query is parsed strictly, params dropped by a lenient parser would not be
+		// covered by the signature
+		query, err := url.ParseQuery(hc.request.URL.RawQuery)
+		if err != nil || len(query[signatureQueryKey]) != 1 || len(query[expiresQueryKey]) != 1 {
+			http.Error(hc.writer, "403 invalid signed url", http.StatusForbidden)
+			return
+		}
+		signature := query.Get(signatureQueryKey)
+		query.Del(signatureQueryKey)
+
+		unix, err := strconv.ParseInt(query.Get(expiresQueryKey), 10, 64)
+		if err != nil {
+			http.Error(hc.writer, "403 invalid signed url", http.StatusForbidden)
+			return
+		}
+
+		expected := sign(key, hc.request.Method, meta.Name, hc.request.URL.Path, query.Encode())
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			http.Error(hc.writer, "403 invalid signed url", http.StatusForbidden)
+			return
+		}
+
+		if timeNow().Unix() > unix {
+			http.Error(hc.writer, "403 signed url expired", http.StatusForbidden)
+			return
+		}
+		hc.Next()
+	}
+}
+
+// sign returns the signature of a request, query is the encoded query without the
+// signature, expiry included
+func sign(key []byte, method, name, path, query string) string {
+	mac := hmac.New(sha256.New, key)
+	for _, part := range []string{method, name, path} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	mac.Write([]byte(query))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,53 @@
+package treerouter
+
+// TrailingSlash is the handling of requests whose path differs from a route only by a
+// trailing slash, e.g. /users/ for /users
+type TrailingSlash int
+
+const (
+	// TrailingSlashUnset follows RedirectTrailingSlash, redirecting when it is set
+	TrailingSlashUnset TrailingSlash = iota
+	// TrailingSlashStrict serves routes only for their exact path, fixed paths found by
+	// RedirectFixedPath must not differ by a trailing slash either
+	TrailingSlashStrict
+	// TrailingSlashRedirect redirects to the path of the route
+	TrailingSlashRedirect
+	// TrailingSlashLenient serves the route under both paths without redirecting
+	TrailingSlashLenient
+	// TrailingSlashStrip makes paths without a trailing slash canonical. Requests with
+	// a trailing slash are redirected to the path without it, which is served by the
+	// route of either path. Paths a route cannot serve in canonical form are kept
+	TrailingSlashStrip
+	// TrailingSlashAdd makes paths with a trailing slash canonical, the other way round
+	// from TrailingSlashStrip
+	TrailingSlashAdd
+)
+
+// canonicalizes reports whether m has a canonical form for every path
+func (m TrailingSlash) canonicalizes() bool {
+	return m == TrailingSlashStrip || m == TrailingSlashAdd
+}
+
+// canonical reports whether p is in the canonical form of m, any path is for the modes
+// without a canonical form
+func (m TrailingSlash) canonical(p string) bool {
+	if p == "" || p == "/" {
+		return true
+	}
+	switch m {
+	case TrailingSlashStrip:
+		return lastChar(p) != '/'
+	case TrailingSlashAdd:
+		return lastChar(p) == '/'
+	}
+	return true
+}
+
+// matches reports whether a request for p gets a route, by an exact match or by the
+// route differing by a trailing slash. Paths are only made canonical when they do, as a
+// param followed by more segments has no trailing slash match
+func (t *methodTree) matches(p string) bool {
+	routeValue := getRouteValue()
+	defer putRouteValue(routeValue)
+	return t.match(p, routeValue)
+}
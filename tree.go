@@ -2,56 +2,57 @@ package treerouter
 
 import (
 	"net/http"
+	"slices"
+	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-type routes []route
-
-type route struct {
-	method string
-	node   *node
-}
-
 type node struct {
 	path string
 
-	// only endpoint node has at least one handler
-	handler  http.Handler
-	children map[byte]*node
+	// only endpoint node has its endpoint set
+	endpoint
+
+	// static children sorted by priority, indices holds the first byte of each child path
+	// at the same position so a child is found without hashing
+	indices  string
+	children []*node
+
+	// number of routes registered through the node, used to order it among its siblings
+	priority uint32
 
 	// each node has at most one param child and one wildcard child
 	paramChild *node
 	wildChild  *node
-
-	// only endpoint node has paramNames
-	paramNames []string
 }
 
-type routeValue struct {
-	params  map[string]string
+// endpoint holds what is registered for a route
+type endpoint struct {
+	// at least one handler
 	handler http.Handler
-	tsr     bool
-}
 
-func newMethodRoot() routes {
-	initPath := "/"
-	return routes{
-		route{method: http.MethodGet, node: newNode(initPath)},
-		route{method: http.MethodPost, node: newNode(initPath)},
-		route{method: http.MethodPut, node: newNode(initPath)},
-		route{method: http.MethodPatch, node: newNode(initPath)},
-		route{method: http.MethodDelete, node: newNode(initPath)},
-	}
+	paramNames []string
+
+	// pattern is the full path the route was registered with,
+	// and basePath the base path of the group that registered it
+	pattern  string
+	basePath string
+
+	// nil if no meta was given at registration
+	meta *RouteMeta
 }
 
-func (m routes) get(method string) *node {
-	for _, methodNode := range m {
-		if methodNode.method == method {
-			return methodNode.node
-		}
-	}
-	return nil
+// routeValue is the result of matching a request path. It is pooled, handlers get a
+// copy of it in their request context, see params.go
+type routeValue struct {
+	params   Params
+	handler  http.Handler
+	pattern  string
+	basePath string
+	meta     *RouteMeta
+	tsr      bool
 }
 
 func newNode(path string) *node {
@@ -72,6 +73,17 @@ func longestCommonString(s1, s2 string) int {
 	return i
 }
 
+// checkPattern panics if a param or wildcard of pattern is malformed
+func checkPattern(pattern string) {
+	for path := pattern; ; {
+		_, end := getFirstParam(path)
+		if end == -1 {
+			return
+		}
+		path = path[end:]
+	}
+}
+
 // get the first param name in the path, return its start and end indexes including ':' or '*'
 func getFirstParam(path string) (int, int) {
 	start, end := -1, -1
@@ -103,31 +115,75 @@ func (n *node) isLeaf() bool {
 	return n.handler != nil
 }
 
-func (n *node) addNode(path string, handlers http.Handler) {
+// child returns the static child whose path starts with c
+func (n *node) child(c byte) *node {
+	for i := range len(n.indices) {
+		if n.indices[i] == c {
+			return n.children[i]
+		}
+	}
+	return nil
+}
+
+// incrementChildPrio increments the priority of the child at pos and returns its new position
+func (n *node) incrementChildPrio(pos int) int {
+	n.children[pos].priority++
+	return n.reorderChild(pos)
+}
+
+// reorderChild moves the child at pos ahead of its less used siblings and returns its new position
+func (n *node) reorderChild(pos int) int {
+	cs := n.children
+	prio := cs[pos].priority
+
+	newPos := pos
+	for ; newPos > 0 && cs[newPos-1].priority < prio; newPos-- {
+		cs[newPos-1], cs[newPos] = cs[newPos], cs[newPos-1]
+	}
+
+	if newPos != pos {
+		n.indices = n.indices[:newPos] + n.indices[pos:pos+1] + n.indices[newPos:pos] + n.indices[pos+1:]
+	}
+	return newPos
+}
+
+// clone returns a copy of n that can be modified without affecting n. The children are
+// shared until they are copied in turn
+func (n *node) clone() *node {
+	c := *n
+	c.children = slices.Clone(n.children)
+	return &c
+}
+
+// addNode inserts path into the tree and returns the endpoint node holding handlers.
+// n must be a copy owned by the caller, the nodes below it are copied before being
+// changed so trees sharing them are left untouched
+func (n *node) addNode(path string, handlers http.Handler) *node {
 	// list of param names in the path
 	paramNames := make([]string, 0)
+	n.priority++
+
+	// the parent of n and the position of n in its children, only set for static children
+	var parent *node
+	pos := 0
 
 	for {
 		l := longestCommonString(n.path, path)
 
-		// split node
+		// split node by inserting the common prefix as its new parent, only static
+		// children can be split as the root and dynamic nodes always share their path
 		if l < len(n.path) {
-			newNode := &node{
-				path:       n.path[l:],
-				handler:    n.handler,
-				children:   n.children,
-				paramChild: n.paramChild,
-				wildChild:  n.wildChild,
-				paramNames: n.paramNames,
+			prefix := &node{
+				path:     n.path[:l],
+				indices:  n.path[l : l+1],
+				children: []*node{n},
+				priority: n.priority,
 			}
+			parent.children[pos] = prefix
 
-			n.path = n.path[:l]
-			n.children = make(map[byte]*node)
-			n.children = map[byte]*node{
-				newNode.path[0]: newNode,
-			}
-			n.handler = nil
-			n.paramNames = nil
+			n.path = n.path[l:]
+			n.priority--
+			n = prefix
 		}
 		if path[0] == ':' {
 			start, end := getFirstParam(path)
@@ -142,53 +198,62 @@ func (n *node) addNode(path string, handlers http.Handler) {
 		}
 
 		// check if node has child matching first character of path
-		if k, exists := n.children[path[0]]; exists {
-			n = k
+		if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+			n.children[i] = n.children[i].clone()
+			parent, pos = n, n.incrementChildPrio(i)
+			n = n.children[pos]
 			continue
 		}
 
 		if path[0] == ':' && n.paramChild != nil {
+			parent = nil
+			n.paramChild = n.paramChild.clone()
 			n = n.paramChild
+			n.priority++
 			continue
 		}
 
 		if path[0] == '*' && n.wildChild != nil {
+			n.wildChild = n.wildChild.clone()
 			n = n.wildChild
+			n.priority++
 			break
 		}
 
-		n.insertChild(path, handlers, paramNames)
-		return
+		return n.insertChild(path, handlers, paramNames)
 	}
 	n.handler = handlers
 	n.paramNames = paramNames
+	return n
 }
 
-// insertChild adds node that has no common path with the parent node
-func (n *node) insertChild(path string, handlers http.Handler, paramNames []string) {
+// insertChild adds node that has no common path with the parent node and returns the endpoint node
+func (n *node) insertChild(path string, handlers http.Handler, paramNames []string) *node {
 	for {
 		start, end := getFirstParam(path)
 		if start == -1 {
-			n.addChild(&node{
-				path:       path,
-				handler:    handlers,
-				paramNames: paramNames,
-			})
-			return
+			leaf := &node{
+				path:     path,
+				endpoint: endpoint{handler: handlers, paramNames: paramNames},
+				priority: 1,
+			}
+			n.addChild(leaf)
+			return leaf
 		}
 
 		var dynamNode, priorNode *node
 
 		if start > 0 {
 			priorNode = &node{
-				path: path[:start],
+				path:     path[:start],
+				priority: 1,
 			}
 		}
 
 		fc := path[start]
 
 		// both param child (:) and wild child (*) uses single character path
-		dynamNode = &node{path: string(fc)}
+		dynamNode = &node{path: string(fc), priority: 1}
 		if fc == ':' {
 			paramNames = append(paramNames, path[start+1:end])
 		}
@@ -204,7 +269,7 @@ func (n *node) insertChild(path string, handlers http.Handler, paramNames []stri
 		if end == len(path) {
 			dynamNode.paramNames = paramNames
 			dynamNode.handler = handlers
-			return
+			return dynamNode
 		}
 		n = dynamNode
 		path = path[end:]
@@ -220,100 +285,237 @@ func (n *node) addChild(c *node) {
 		n.wildChild = c
 		return
 	}
-	if n.children == nil {
-		n.children = make(map[byte]*node)
-	}
-	n.children[c.path[0]] = c
+	n.indices += c.path[:1]
+	n.children = append(n.children, c)
+	n.reorderChild(len(n.children) - 1)
 }
 
-func (n *node) match(path string) *routeValue {
-	return n.matchRoute(path, []string{})
-}
+// remove clears the endpoint registered with pattern under n, where path is the part of
+// pattern left to match at n, and prunes the nodes left without routes. It returns the
+// removed endpoint, or nil if pattern is not registered. n must be a copy owned by the
+// caller, the nodes below it are copied before being changed
+func (n *node) remove(path, pattern string) *endpoint {
+	switch n.path {
+	case ":":
+		_, end := getFirstParam(path)
+		path = path[end:]
+	case "*":
+		// wildcards always end the pattern
+		path = ""
+	default:
+		if !strings.HasPrefix(path, n.path) {
+			return nil
+		}
+		path = path[len(n.path):]
+	}
 
-func newRouteValue(n *node, paramVals []string, tsr bool) *routeValue {
-	if !n.isLeaf() {
-		return nil
+	if path == "" {
+		if !n.isLeaf() || n.pattern != pattern {
+			return nil
+		}
+		removed := n.endpoint
+		n.endpoint = endpoint{}
+		n.priority--
+		return &removed
 	}
 
-	if len(n.paramNames) != len(paramVals) {
-		panic("missing param name(s) or param val(s)")
+	var removed *endpoint
+
+	switch path[0] {
+	case ':':
+		if n.paramChild == nil {
+			return nil
+		}
+		c := n.paramChild.clone()
+		if removed = c.remove(path, pattern); removed == nil {
+			return nil
+		}
+		n.paramChild = c
+		if c.isEmpty() {
+			n.paramChild = nil
+		}
+	case '*':
+		if n.wildChild == nil {
+			return nil
+		}
+		c := n.wildChild.clone()
+		if removed = c.remove(path, pattern); removed == nil {
+			return nil
+		}
+		n.wildChild = c
+		if c.isEmpty() {
+			n.wildChild = nil
+		}
+	default:
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			return nil
+		}
+		c := n.children[i].clone()
+		if removed = c.remove(path, pattern); removed == nil {
+			return nil
+		}
+		n.children[i] = c.merged()
+		if c.isEmpty() {
+			n.indices = n.indices[:i] + n.indices[i+1:]
+			n.children = slices.Delete(n.children, i, i+1)
+		} else {
+			n.demoteChild(i)
+		}
 	}
+	n.priority--
+	return removed
+}
 
-	if n.handler == nil {
-		panic("missing handler")
+// isEmpty reports whether no route goes through n
+func (n *node) isEmpty() bool {
+	return !n.isLeaf() && len(n.children) == 0 && n.paramChild == nil && n.wildChild == nil
+}
+
+// merged returns the static node n joined with its only child when n has no route of its
+// own, otherwise n itself
+func (n *node) merged() *node {
+	if n.isLeaf() || len(n.children) != 1 || n.paramChild != nil || n.wildChild != nil {
+		return n
 	}
+	c := n.children[0].clone()
+	c.path = n.path + c.path
+	c.priority = n.priority
+	return c
+}
 
-	params := make(map[string]string)
-	for i := range len(n.paramNames) {
-		params[n.paramNames[i]] = paramVals[i]
+// demoteChild moves the child at pos behind its more used siblings
+func (n *node) demoteChild(pos int) {
+	cs := n.children
+	prio := cs[pos].priority
+
+	newPos := pos
+	for ; newPos < len(cs)-1 && cs[newPos+1].priority > prio; newPos++ {
+		cs[newPos+1], cs[newPos] = cs[newPos], cs[newPos+1]
 	}
 
-	return &routeValue{
-		params:  params,
-		handler: n.handler,
-		tsr:     tsr,
+	if newPos != pos {
+		n.indices = n.indices[:pos] + n.indices[pos+1:newPos+1] + n.indices[pos:pos+1] + n.indices[newPos+1:]
 	}
 }
 
-func (n *node) matchRoute(path string, paramValues []string) *routeValue {
-	l, k := len(n.path), len(path)
-	if n.path == ":" {
-		// find the index at the next '/' or EOL of path
-		end := 0
-		for end < len(path) && path[end] != '/' {
-			end++
+// prefixed returns the node under n holding every route whose pattern starts with the
+// static prefix, or nil if there is none
+func (n *node) prefixed(prefix string) *node {
+	for {
+		if len(prefix) <= len(n.path) {
+			if strings.HasPrefix(n.path, prefix) {
+				return n
+			}
+			return nil
 		}
-		paramValues = append(paramValues, path[:end])
-		path = path[end:]
-	} else if l <= k && n.path == path[:l] {
-		path = path[l:]
-	} else {
-		if l == k+1 && n.path[:k] == path && n.path[k] == '/' {
-			return newRouteValue(n, paramValues, true)
+		if !strings.HasPrefix(prefix, n.path) {
+			return nil
+		}
+		prefix = prefix[len(n.path):]
+		if n = n.child(prefix[0]); n == nil {
+			return nil
 		}
-		return nil
 	}
+}
 
-	if len(path) == 0 {
-		return newRouteValue(n, paramValues, false)
-	}
-	if node, exists := n.children[path[0]]; exists {
-		if v := node.matchRoute(path, paramValues); v != nil {
-			return v
+// walk calls fn for every endpoint node under n
+func (n *node) walk(fn func(leaf *node) error) error {
+	if n.isLeaf() {
+		if err := fn(n); err != nil {
+			return err
 		}
-	} else if path == "/" {
-		return newRouteValue(n, paramValues, true)
 	}
 
-	// if no matching segments found try matching parameter nodes first
+	// visit static children in byte order rather than priority order so the
+	// result is stable, then the param and wildcard children
+	order := make([]int, len(n.children))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return n.indices[order[i]] < n.indices[order[j]] })
+
+	for _, i := range order {
+		if err := n.children[i].walk(fn); err != nil {
+			return err
+		}
+	}
 	if n.paramChild != nil {
-		if v := n.paramChild.matchRoute(path, paramValues); v != nil {
-			return v
+		if err := n.paramChild.walk(fn); err != nil {
+			return err
 		}
 	}
-
 	if n.wildChild != nil {
-		v := newRouteValue(n.wildChild, paramValues, false)
-		// set wildcard param name to "*"
-		v.params["*"] = path
-		return v
+		if err := n.wildChild.walk(fn); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
-func (n *node) findCaseInsensitivePath(path string, tsr bool) (string, bool) {
-	buffer := make([]byte, 0, len(path)+1)
-	result := n.findCaseInsensitivePathRec(path, buffer, tsr)
+// match looks up path and stores the matching endpoint and its params in v.
+// It returns false if neither an endpoint nor a trailing slash redirect was found
+func (n *node) match(path string, v *routeValue) bool {
+	v.params = v.params[:0]
+	return n.matchRoute(path, v)
+}
+
+// setLeaf stores endpoint node n in v and names the param values collected so far
+func (v *routeValue) setLeaf(n *node, tsr bool) bool {
+	if !n.isLeaf() {
+		return false
+	}
 
-	if result == nil {
-		return "", false
+	if len(n.paramNames) != len(v.params) {
+		panic("missing param name(s) or param val(s)")
 	}
 
-	return string(result), true
+	for i := range len(n.paramNames) {
+		v.params[i].Key = n.paramNames[i]
+	}
+
+	v.handler = n.handler
+	v.pattern = n.pattern
+	v.basePath = n.basePath
+	v.meta = n.meta
+	v.tsr = tsr
+	return true
 }
 
-func (n *node) findCaseInsensitivePathRec(path string, buffer []byte, tsr bool) []byte {
+// size of the backtracking stack kept on the goroutine stack, deeper trees spill to the heap
+const matchStackSize = 16
+
+// kinds of children left to try at a node when backtracking, static children are
+// always tried first
+const (
+	tryParam uint8 = iota
+	tryWild
+)
+
+// matchFrame is a node on the current lookup path whose other children may still match
+type matchFrame struct {
+	n *node
+	// path left to match after the segment of n
+	path string
+	// params or bytes collected before n, restored when leaving n
+	mark int
+	next uint8
+}
+
+// result of matching the segment of a single node
+type stepResult uint8
+
+const (
+	// the segment matched, continue with the children of the node
+	stepDescend stepResult = iota
+	// the lookup cannot continue below the node
+	stepFail
+	// the lookup ended at the node with a result
+	stepFound
+)
+
+// consume matches the segment of n against path and returns the rest of the path
+func (n *node) consume(path string, v *routeValue) (string, stepResult) {
 	l, k := len(n.path), len(path)
 	if n.path == ":" {
 		// find the index at the next '/' or EOL of path
@@ -321,49 +523,224 @@ func (n *node) findCaseInsensitivePathRec(path string, buffer []byte, tsr bool)
 		for end < len(path) && path[end] != '/' {
 			end++
 		}
-		buffer = append(buffer, []byte(path[:end])...)
+		v.params = append(v.params, Param{Value: path[:end]})
 		path = path[end:]
-	} else if l <= k && strings.EqualFold(n.path, path[:l]) {
-		buffer = append(buffer, []byte(n.path)...)
+	} else if l <= k && n.path == path[:l] {
 		path = path[l:]
 	} else {
-		if tsr && l == k+1 && strings.EqualFold(n.path[:k], path) && n.path[k] == '/' {
-			buffer = append(buffer, []byte(n.path[:k])...)
-			return buffer
+		if l == k+1 && n.path[:k] == path && n.path[k] == '/' && v.setLeaf(n, true) {
+			return "", stepFound
 		}
-		return nil
+		return "", stepFail
 	}
 
-	if len(path) == 0 || (tsr && path == "/") {
-		return buffer
+	if len(path) == 0 {
+		if v.setLeaf(n, false) {
+			return "", stepFound
+		}
+		return "", stepFail
 	}
+	if path == "/" && n.child('/') == nil {
+		if v.setLeaf(n, true) {
+			return "", stepFound
+		}
+		return "", stepFail
+	}
+	return path, stepDescend
+}
+
+// matchRoute walks the tree iteratively, trying static children first, then the param
+// child and the wildcard child last, backtracking through an explicit stack
+func (n *node) matchRoute(path string, v *routeValue) bool {
+	var buf [matchStackSize]matchFrame
+	stack := buf[:0]
+
+	for {
+		mark := len(v.params)
+		rest, result := n.consume(path, v)
+		if result == stepFound {
+			return true
+		}
 
-	lower := byte(unicode.ToLower(rune(path[0])))
-	if node, exists := n.children[lower]; exists {
-		if v := node.findCaseInsensitivePathRec(path, buffer, tsr); v != nil {
-			return v
+		if result == stepFail {
+			v.params = v.params[:mark]
+		} else {
+			stack = append(stack, matchFrame{n: n, path: rest, mark: mark, next: tryParam})
+			if c := n.child(rest[0]); c != nil {
+				n, path = c, rest
+				continue
+			}
+		}
+
+		// a failed child restores the params it collected, so the params of the frame
+		// on top of the stack are those collected up to and including its node
+		n = nil
+		for n == nil && len(stack) > 0 {
+			f := &stack[len(stack)-1]
+			if f.next == tryParam && f.n.paramChild != nil {
+				f.next = tryWild
+				n, path = f.n.paramChild, f.path
+				continue
+			}
+
+			if f.n.wildChild != nil && v.setLeaf(f.n.wildChild, false) {
+				// set wildcard param name to "*"
+				v.params = append(v.params, Param{Key: "*", Value: f.path})
+				return true
+			}
+			v.params = v.params[:f.mark]
+			stack = stack[:len(stack)-1]
+		}
+
+		if n == nil {
+			return false
+		}
+	}
+}
+
+// foldFrame is a position in the tree reached while looking up a path ignoring case
+type foldFrame struct {
+	n *node
+	// bytes of the path of n matched so far, radix splits may fall inside a rune so
+	// a rune can end in the middle of a node
+	off int
+	// path left to match
+	path string
+	// bytes of the buffer before the frame, restored when trying another alternative
+	mark int
+	// next alternative to try: the end of the path, the case variants of the next rune,
+	// then the param and wildcard children
+	next int
+}
+
+// findCaseInsensitivePath returns the path of the endpoint matching path ignoring case,
+// with the casing of the tree for static segments and the casing of path for params.
+// Runes are compared with Unicode simple case folding
+func (n *node) findCaseInsensitivePath(path string, tsr bool) (string, bool) {
+	buffer := make([]byte, 0, len(path)+1)
+	var buf [matchStackSize]foldFrame
+	stack := append(buf[:0], foldFrame{n: n, path: path})
+
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		buffer = buffer[:f.mark]
+		alt := f.next
+		f.next++
+
+		if alt == 0 {
+			if found, ok := f.n.foldEnd(f.off, f.path, buffer, tsr); ok {
+				return string(found), true
+			}
+			continue
+		}
+		if f.path == "" {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		var variants [8]rune
+		r, size := utf8.DecodeRuneInString(f.path)
+		folds := foldVariants(r, variants[:0])
+		if alt <= len(folds) {
+			var rb [utf8.UTFMax]byte
+			enc := append(rb[:0], f.path[0])
+			// invalid utf-8 is only matched byte for byte
+			if r != utf8.RuneError || size > 1 {
+				enc = utf8.AppendRune(rb[:0], folds[alt-1])
+			}
+			if c, off, ok := f.n.advance(f.off, enc); ok {
+				buffer = append(buffer, enc...)
+				stack = append(stack, foldFrame{n: c, off: off, path: f.path[size:], mark: len(buffer)})
+			}
+			continue
+		}
+
+		// params and wildcards start after the path of their parent
+		atEnd := f.off == len(f.n.path)
+		switch alt - len(folds) {
+		case 1:
+			if atEnd && f.n.paramChild != nil {
+				end := strings.IndexByte(f.path, '/')
+				if end < 0 {
+					end = len(f.path)
+				}
+				buffer = append(buffer, f.path[:end]...)
+				stack = append(stack, foldFrame{n: f.n.paramChild, off: 1, path: f.path[end:], mark: len(buffer)})
+			}
+		case 2:
+			// match wildcard child last as it has lowest priority
+			if atEnd && f.n.wildChild != nil {
+				buffer = append(buffer, f.path...)
+				return string(buffer), true
+			}
+		default:
+			stack = stack[:len(stack)-1]
 		}
 	}
+	return "", false
+}
 
-	upper := byte(unicode.ToUpper(rune(path[0])))
-	if node, exists := n.children[upper]; exists {
-		if v := node.findCaseInsensitivePathRec(path, buffer, tsr); v != nil {
-			return v
+// foldVariants appends the runes equal to r under simple case folding to variants,
+// lower case first, then upper case, then the other forms
+func foldVariants(r rune, variants []rune) []rune {
+	lower, upper := unicode.ToLower(r), unicode.ToUpper(r)
+	variants = append(variants, lower)
+	if upper != lower {
+		variants = append(variants, upper)
+	}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f != lower && f != upper {
+			variants = append(variants, f)
 		}
 	}
+	if r != lower && r != upper {
+		variants = append(variants, r)
+	}
+	return variants
+}
 
-	// if no matching segments found try matching parameter nodes first
-	if n.paramChild != nil {
-		if v := n.paramChild.findCaseInsensitivePathRec(path, buffer, tsr); v != nil {
-			return v
+// advance matches b against the tree from the position off in the path of n, moving
+// on to static children, and returns the position after b
+func (n *node) advance(off int, b []byte) (*node, int, bool) {
+	for _, c := range b {
+		if off == len(n.path) {
+			if n = n.child(c); n == nil {
+				return nil, 0, false
+			}
+			off = 0
+		}
+		if n.path[off] != c {
+			return nil, 0, false
 		}
+		off++
 	}
+	return n, off, true
+}
 
-	// match wildcard child last as it has lowest priority
-	if n.wildChild != nil {
-		buffer = append(buffer, []byte(path)...)
-		return buffer
+// foldEnd reports whether the lookup ends at the position off in the path of n with
+// path left, appending a missing trailing slash to buffer
+func (n *node) foldEnd(off int, path string, buffer []byte, tsr bool) ([]byte, bool) {
+	if off < len(n.path) {
+		// the path lacks the trailing slash of an endpoint
+		if tsr && path == "" && n.path[off:] == "/" && n.isLeaf() {
+			return append(buffer, '/'), true
+		}
+		return nil, false
 	}
 
-	return nil
+	if path == "" {
+		if n.isLeaf() {
+			return buffer, true
+		}
+		// the path lacks the trailing slash of a child endpoint
+		if c := n.child('/'); tsr && c != nil && c.path == "/" && c.isLeaf() {
+			return append(buffer, '/'), true
+		}
+		return nil, false
+	}
+	// the path has an extra trailing slash
+	if tsr && path == "/" && n.isLeaf() {
+		return buffer, true
+	}
+	return nil, false
 }
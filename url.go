@@ -0,0 +1,90 @@
+package treerouter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrRouteNotFound is returned when no route is registered with the requested name
+var ErrRouteNotFound = errors.New("treerouter: route not found")
+
+// URL builds the path of the route registered with name, filling its params from
+// key-value pairs. The catch-all segment uses "*" as its key, e.g.
+//
+//	router.URL("user-file", "id", "42", "*", "docs/report.pdf") // "/users/42/files/docs/report.pdf"
+func (router *Router) URL(name string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("treerouter: route %q: params must be given as key-value pairs", name)
+	}
+
+	pattern, ok := router.routes.load().namedPattern(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrRouteNotFound, name)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	var sb strings.Builder
+	// a param name may appear more than once in the pattern, so used counts distinct keys
+	used := make(map[string]bool, len(values))
+	for {
+		start, end := getFirstParam(pattern)
+		if start == -1 {
+			sb.WriteString(pattern)
+			break
+		}
+		sb.WriteString(pattern[:start])
+
+		isWild := pattern[start] == '*'
+		key := pattern[start+1 : end]
+		if isWild {
+			key = "*"
+		}
+
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("treerouter: route %q: missing param %q", name, key)
+		}
+		used[key] = true
+
+		escaped, err := escapeParam(value, isWild)
+		if err != nil {
+			return "", fmt.Errorf("treerouter: route %q: param %q: %w", name, key, err)
+		}
+		sb.WriteString(escaped)
+		pattern = pattern[end:]
+	}
+
+	if len(used) != len(values) {
+		return "", fmt.Errorf("treerouter: route %q: unknown params given", name)
+	}
+	return sb.String(), nil
+}
+
+// escapeParam escapes a param value so that it matches exactly the segment(s) it fills.
+// Only the catch-all value may span several segments
+func escapeParam(value string, isWild bool) (string, error) {
+	if value == "" {
+		return "", errors.New("value cannot be empty")
+	}
+
+	segments := []string{value}
+	if isWild {
+		segments = strings.Split(value, "/")
+	} else if strings.Contains(value, "/") {
+		return "", errors.New("value cannot contain '/'")
+	}
+
+	for i, segment := range segments {
+		if segment == "." || segment == ".." {
+			return "", errors.New("value cannot contain dot segments")
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/"), nil
+}
@@ -1,36 +1,47 @@
 package treerouter
 
 import (
-	"context"
 	"net/http"
 	"path"
+	"strconv"
+	"strings"
 )
 
-type RouteParams map[string]string
-
 type contextKey struct {
 	name string
 }
 
-var paramKey = &contextKey{
-	name: "params",
+var routeKey = &contextKey{
+	name: "route",
 }
 
+// GetParam returns the value of the param key of the route that matched r,
+// or an empty string if there is no such param
 func GetParam(r *http.Request, key string) string {
-	params, ok := r.Context().Value(paramKey).(map[string]string)
+	c, ok := routeContextFrom(r)
+	if !ok {
+		return ""
+	}
+	return c.params.Get(key)
+}
+
+// RoutePattern returns the registered pattern of the route that matched r, e.g. /users/:id.
+// It returns an empty string if r has not been matched by the router
+func RoutePattern(r *http.Request) string {
+	c, ok := routeContextFrom(r)
 	if !ok {
-		panic("Invalid context value")
+		return ""
 	}
-	return params[key]
+	return c.pattern
 }
 
-func addParams(r *http.Request, params map[string]string) *http.Request {
-	if len(params) == 0 {
-		return r
+// RouteBasePath returns the base path of the group that registered the route matching r
+func RouteBasePath(r *http.Request) string {
+	c, ok := routeContextFrom(r)
+	if !ok {
+		return ""
 	}
-	ctx := r.Context()
-	ctx = context.WithValue(ctx, paramKey, params)
-	return r.WithContext(ctx)
+	return c.basePath
 }
 
 func lastChar(s string) byte {
@@ -41,6 +52,70 @@ func lastChar(s string) byte {
 	return s[len(s)-1]
 }
 
+// cleanPath cleans p like path.Clean while keeping its trailing slash
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	cleaned := path.Clean(p)
+	if lastChar(p) == '/' && cleaned != "/" {
+		return cleaned + "/"
+	}
+	return cleaned
+}
+
+// hasEncodedSlash reports whether the escaped path rawPath has an encoded slash
+func hasEncodedSlash(rawPath string) bool {
+	return strings.Contains(rawPath, "%2F") || strings.Contains(rawPath, "%2f")
+}
+
+// unescapeExceptSlash decodes the escapes of rawPath except those of '/' and '%', which
+// are kept so the result can be split into segments and unescaped once more
+func unescapeExceptSlash(rawPath string) string {
+	var b strings.Builder
+	b.Grow(len(rawPath))
+	for i := 0; i < len(rawPath); i++ {
+		c := rawPath[i]
+		if c == '%' && i+2 < len(rawPath) {
+			if d, err := strconv.ParseUint(rawPath[i+1:i+3], 16, 8); err == nil {
+				if d == '/' || d == '%' {
+					b.WriteString(strings.ToUpper(rawPath[i : i+3]))
+				} else {
+					b.WriteByte(byte(d))
+				}
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// escapePath escapes a path returned by unescapeExceptSlash, keeping its escapes
+func escapePath(p string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '%' && i+2 < len(p):
+			b.WriteString(p[i : i+3])
+			i += 2
+		case isPathChar(c):
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
 // joins absolute path with relative path while preserving end slash
 func joinPaths(absolutePath, relativePath string) string {
 	if relativePath == "" {